@@ -3,8 +3,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
@@ -12,12 +10,21 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
 )
 
+// connIDHeader is the correlation ID the proxy mints for the connection;
+// we just log what we observe rather than minting our own.
+const connIDHeader = "X-Conn-ID"
+
+var logger *zap.Logger
+var sugar *zap.SugaredLogger
+
 type WSClient struct {
 	url         string
 	id          int
 	conn        *websocket.Conn
+	connID      string
 	done        chan struct{}
 	interrupt   chan os.Signal
 	ticker      *time.Ticker
@@ -42,33 +49,22 @@ func NewWSClient(url string, id int) *WSClient {
 	}
 }
 
-func (c *WSClient) handleRedirect() (*websocket.Conn, *http.Response, error) {
-	conn, resp, err := websocket.DefaultDialer.Dial(c.url, nil)
-	if err != nil && resp != nil {
-		if resp.StatusCode == http.StatusTemporaryRedirect ||
-			resp.StatusCode == http.StatusMovedPermanently ||
-			resp.StatusCode == http.StatusFound {
-			redirectURL := resp.Header.Get("Location")
-			if redirectURL != "" {
-				log.Printf("Following redirect to: %s", redirectURL)
-				return websocket.DefaultDialer.Dial(redirectURL, nil)
-			}
-		}
-	}
-	return conn, resp, err
-}
-
+// connect dials c.url and performs the WebSocket handshake, including the
+// TLS handshake for wss:// URLs via clientDialer's TLSClientConfig. It does
+// not follow HTTP redirects: chunk0-1 turned the proxy into a true reverse
+// WebSocket proxy that performs the upgrade to the backend itself, so the
+// client never receives a redirect to follow in the first place.
 func (c *WSClient) connect() error {
-	var resp *http.Response
-	var err error
-
-	c.conn, resp, err = c.handleRedirect()
+	conn, resp, err := clientDialer.Dial(c.url, nil)
 	if err != nil {
 		if resp != nil {
 			return fmt.Errorf("dial %d: %v (status: %d)", c.id, err, resp.StatusCode)
 		}
 		return fmt.Errorf("dial %d: %v", c.id, err)
 	}
+	c.conn = conn
+	c.connID = resp.Header.Get(connIDHeader)
+	sugar.Infow("connected", "client_id", c.id, "conn_id", c.connID)
 	return nil
 }
 
@@ -87,18 +83,18 @@ func (c *WSClient) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-				log.Printf("client %d: connection closed normally", c.id)
+				sugar.Infow("connection closed normally", "client_id", c.id, "conn_id", c.connID)
 			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("read %d: unexpected close error: %v", c.id, err)
+				sugar.Warnw("unexpected close error", "client_id", c.id, "conn_id", c.connID, "error", err)
 			} else {
 				var netErr *websocket.CloseError
 				if errors.As(err, &netErr) {
-					log.Printf("read %d: websocket close error: %v", c.id, netErr)
+					sugar.Warnw("websocket close error", "client_id", c.id, "conn_id", c.connID, "error", netErr)
 				}
 			}
 			return
 		}
-		log.Printf("recv %d: %s", c.id, message)
+		sugar.Debugw("recv", "client_id", c.id, "conn_id", c.connID, "message", string(message))
 	}
 }
 
@@ -134,11 +130,11 @@ func (c *WSClient) Run(wg *sync.WaitGroup) {
 		c.mu.Unlock()
 
 		if err := c.connect(); err != nil {
-			log.Printf("%v, retrying in %v...", err, c.retryDelay)
+			sugar.Warnw("connect failed, retrying", "client_id", c.id, "retry_delay", c.retryDelay, "error", err)
 			c.retryCount++
 
 			if c.retryCount >= c.maxRetries {
-				log.Printf("Retry count exceeded for client %d, reconnecting to proxy...", c.id)
+				sugar.Warnw("retry count exceeded, reconnecting to proxy", "client_id", c.id)
 				c.url = c.originalURL
 				c.retryCount = 0
 			}
@@ -156,22 +152,22 @@ func (c *WSClient) Run(wg *sync.WaitGroup) {
 			select {
 			case <-c.done:
 				if closeErr := c.conn.CloseHandler()(websocket.CloseAbnormalClosure, ""); closeErr != nil {
-					log.Printf("connection %d closed abnormally: %v", c.id, closeErr)
+					sugar.Warnw("connection closed abnormally", "client_id", c.id, "error", closeErr)
 				}
 				break loop
 
 			case <-c.ticker.C:
 				if err := c.writePump(); err != nil {
-					log.Printf("write %d: %v", c.id, err)
+					sugar.Warnw("write failed", "client_id", c.id, "error", err)
 					break loop
 				}
 
 			case <-c.interrupt:
-				log.Printf("interrupt %d", c.id)
+				sugar.Infow("interrupt", "client_id", c.id)
 				err := c.conn.WriteMessage(websocket.CloseMessage,
 					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				if err != nil {
-					log.Printf("write close %d: %v", c.id, err)
+					sugar.Warnw("write close failed", "client_id", c.id, "error", err)
 				}
 				select {
 				case <-c.done:
@@ -182,18 +178,25 @@ func (c *WSClient) Run(wg *sync.WaitGroup) {
 		}
 
 		c.cleanup()
-		log.Printf("Connection %d lost, reconnecting...", c.id)
+		sugar.Infow("connection lost, reconnecting", "client_id", c.id)
 		time.Sleep(time.Second)
 	}
 }
 
 func main() {
+	var err error
+	logger, err = newLogger()
+	if err != nil {
+		panic(fmt.Sprintf("failed to build logger: %v", err))
+	}
+	sugar = logger.Sugar()
+	defer logger.Sync()
+
 	numConnections := 5
 	if len(os.Args) > 1 {
-		var err error
 		numConnections, err = strconv.Atoi(os.Args[1])
 		if err != nil {
-			log.Fatal("Invalid number of connections:", err)
+			sugar.Fatalw("invalid number of connections", "error", err)
 		}
 	}
 
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/gorilla/websocket"
+)
+
+// clientDialer is used for every connect() call. It behaves exactly like
+// websocket.DefaultDialer for ws:// URLs; for wss:// URLs it additionally
+// trusts the CA bundle named by CLIENT_TLS_CA, if set, alongside the system
+// root store.
+var clientDialer = buildDialer()
+
+func buildDialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+
+	caFile := os.Getenv("CLIENT_TLS_CA")
+	if caFile == "" {
+		return &dialer
+	}
+
+	caCert, err := os.ReadFile(caFile)
+	if err != nil {
+		panic(fmt.Sprintf("failed to read CLIENT_TLS_CA: %v", err))
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(caCert) {
+		panic(fmt.Sprintf("no certificates found in CLIENT_TLS_CA %s", caFile))
+	}
+
+	dialer.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &dialer
+}
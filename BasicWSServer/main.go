@@ -1,31 +1,48 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
 )
 
 // Constants
 const (
 	statsLogDuration      = 10
 	loadSheddingThreshold = 50
+
+	// tlsPort is where the TLS listener binds when TLS_CERT_FILE/TLS_KEY_FILE
+	// are set. It rides along in the TLS_PORT Cloud Map attribute so the
+	// proxy knows where to dial wss instances; AWS_INSTANCE_IPV4 carries no
+	// port of its own.
+	tlsPort = "8443"
 )
 
+// connIDHeader is where the proxy puts the correlation ID it minted for
+// this connection; we reuse it rather than generating a second ID so logs
+// on both sides of the hop join on the same value.
+const connIDHeader = "X-Conn-ID"
+
 // Global variables
 var (
 	// Connection tracking
@@ -37,6 +54,10 @@ var (
 	// Server info
 	hostname, _ = os.Hostname()
 
+	// Logging
+	logger *zap.Logger
+	sugar  *zap.SugaredLogger
+
 	// AWS Service Discovery related
 	serviceDiscoveryClient *servicediscovery.ServiceDiscovery
 	serviceID              string
@@ -50,12 +71,23 @@ var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins (for testing; restrict in production)
+		// Allow all origins (for testing; restrict in production). Logged
+		// against the real client IP rather than the load balancer's so the
+		// decision is traceable back to the actual caller.
+		sugar.Infow("upgrade request", "hostname", hostname, "client_ip", clientIPFromContext(r.Context()), "origin", r.Header.Get("Origin"))
+		return true
 	},
 }
 
 // Initialization
 func init() {
+	var err error
+	logger, err = newLogger()
+	if err != nil {
+		panic(fmt.Sprintf("failed to build logger: %v", err))
+	}
+	sugar = logger.Sugar()
+
 	initializeAWS()
 	go monitorStats()
 }
@@ -71,7 +103,7 @@ func initializeAWS() {
 	metadataURI := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
 
 	if metadataURI == "" {
-		log.Printf("Hostname: %s - ECS_CONTAINER_METADATA_URI_V4 environment variable must be set", hostname)
+		sugar.Warnw("ECS_CONTAINER_METADATA_URI_V4 environment variable must be set", "hostname", hostname)
 	}
 
 	// Extract task ID from metadata URI - format is http://<ip>/<version>/<id>
@@ -79,17 +111,17 @@ func initializeAWS() {
 	instanceID = strings.Split(parts[len(parts)-1], "-")[0] // First part before hyphen is the instance ID
 
 	if serviceID == "" || instanceID == "" {
-		log.Printf("Hostname: %s - SERVICE_ID and INSTANCE_ID environment variables must be set", hostname)
+		sugar.Warnw("SERVICE_ID and INSTANCE_ID environment variables must be set", "hostname", hostname)
 	}
 
 	// Get public IP
 	var err error
 	publicIP, err = getPublicIPFromPrivateIP()
 	if err != nil {
-		log.Printf("Failed to get public IP: %v", err)
+		sugar.Errorw("failed to get public IP", "error", err)
 	}
 
-	log.Printf("Hostname: %s - instanceId: %s, namespaceId: %s, serviceId: %s, publicIP: %s", hostname, instanceID, namespaceId, serviceID, publicIP)
+	sugar.Infow("initialized", "hostname", hostname, "instance_id", instanceID, "namespace_id", namespaceId, "service_id", serviceID, "public_ip", publicIP)
 }
 
 // Monitoring and stats
@@ -108,8 +140,14 @@ func monitorStats() {
 		updateServiceDiscovery(connections)
 
 		// Log stats
-		log.Printf("Hostname: %s - Stats - Memory utilization: %d%%, CPU utilization: %f, Active connections: %d, Dropped requests %d, Cumulative Dropped Requests: %d",
-			hostname, memoryUtilizationPercent, cpuUsage, connections, droppedRequests, cumulativeDroppedRequests)
+		sugar.Infow("stats",
+			"hostname", hostname,
+			"memory_utilization_percent", memoryUtilizationPercent,
+			"cpu_utilization_percent", cpuUsage,
+			"active_connections", connections,
+			"dropped_requests", droppedRequests,
+			"cumulative_dropped_requests", cumulativeDroppedRequests,
+		)
 
 		// Reset counters
 		atomic.StoreInt32(&droppedRequests, 0)
@@ -189,7 +227,7 @@ func getPublicIPFromEC2(privateIP string) (string, error) {
 	})
 
 	resultJSON, _ := json.Marshal(result)
-	log.Printf("DescribeNetworkInterfaces result: %s, privateIP: %s", string(resultJSON), privateIP)
+	sugar.Debugw("describe network interfaces", "result", string(resultJSON), "private_ip", privateIP)
 	if err != nil {
 		return "", fmt.Errorf("failed to describe network interfaces: %v", err)
 	}
@@ -205,6 +243,17 @@ func updateServiceDiscovery(connections int32) {
 	attributes := make(map[string]*string)
 	attributes["ACTIVE_CONNECTIONS"] = aws.String(fmt.Sprintf("%d", connections))
 	attributes["INSTANCE_PUBLIC_IPV4"] = aws.String(publicIP)
+	scheme := instanceScheme()
+	attributes["SCHEME"] = aws.String(scheme)
+	if scheme == "wss" {
+		attributes["TLS_PORT"] = aws.String(tlsPort)
+	}
+
+	// Once shutdown has deregistered this instance, a heartbeat must not
+	// register it again.
+	if atomic.LoadInt32(&shuttingDown) == 1 {
+		return
+	}
 
 	_, err := serviceDiscoveryClient.RegisterInstance(&servicediscovery.RegisterInstanceInput{
 		ServiceId:  aws.String(serviceID),
@@ -212,35 +261,78 @@ func updateServiceDiscovery(connections int32) {
 		Attributes: attributes,
 	})
 	if err != nil {
-		log.Printf("Failed to update service discovery: %v", err)
+		sugar.Errorw("failed to update service discovery", "error", err)
 	}
 }
 
 // Connection handling
 func handleConnections(w http.ResponseWriter, r *http.Request) {
+	connID := r.Header.Get(connIDHeader)
+	if connID == "" {
+		connID = uuid.NewString()
+	}
+	connLog := logger.With(zap.String("conn_id", connID), zap.String("instance_id", instanceID), zap.String("hostname", hostname))
+
+	if atomic.LoadInt32(&shuttingDown) == 1 {
+		// The proxy's service-discovery cache can stay stale for up to
+		// CacheRefreshInterval after deregisterInstance, so it may still
+		// route fresh upgrades here during the grace window. Reject them
+		// outright rather than registering them after closeAll already ran;
+		// otherwise they'd never get a close frame and would sit on
+		// waitForDrain until the grace period is cut short at exit.
+		connLog.Warn("rejecting upgrade: server is shutting down")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
 	if !checkMemoryUsage() {
+		connLog.Warn("dropping request: load shedding", zap.String("client_ip", clientIPFromContext(r.Context())))
 		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 		atomic.AddInt32(&droppedRequests, 1)
 		atomic.AddInt64(&cumulativeDroppedRequests, 1)
 		return
 	}
 
+	clientIP := clientIPFromContext(r.Context())
+	if clientIP == "" {
+		clientIP = r.RemoteAddr
+	}
+	connLog = connLog.With(zap.String("client_id", clientIP))
+
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Fatal(err)
+		connLog.Error("failed to upgrade connection", zap.Error(err))
+		return
 	}
 	defer ws.Close()
 
+	connLog.Info("client connected")
+
 	incrementConnections()
 	defer decrementConnections()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writeMu := registry.register(connID, ws, cancel)
+	defer registry.unregister(connID)
+
+	go func() {
+		<-ctx.Done()
+		ws.SetReadDeadline(time.Now())
+	}()
+
 	for {
 		messageType, p, err := ws.ReadMessage()
 		if err != nil {
+			connLog.Debug("read error, closing connection", zap.Error(err))
 			return
 		}
 
-		if err := ws.WriteMessage(messageType, p); err != nil {
+		writeMu.Lock()
+		err = ws.WriteMessage(messageType, p)
+		writeMu.Unlock()
+		if err != nil {
+			connLog.Debug("write error, closing connection", zap.Error(err))
 			return
 		}
 	}
@@ -273,7 +365,7 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 }
 
 func healthCheckWithLoadShedding(w http.ResponseWriter, r *http.Request) {
-	if checkMemoryUsage() {
+	if atomic.LoadInt32(&shuttingDown) == 0 && checkMemoryUsage() {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "OK")
 		return
@@ -282,12 +374,100 @@ func healthCheckWithLoadShedding(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Service Unavailable")
 }
 
+// instanceScheme reports which scheme the proxy should dial this instance
+// with, so the answer can ride along in the Cloud Map attributes the very
+// same heartbeat already writes.
+func instanceScheme() string {
+	if os.Getenv("TLS_CERT_FILE") != "" && os.Getenv("TLS_KEY_FILE") != "" {
+		return "wss"
+	}
+	return "ws"
+}
+
 func main() {
+	defer logger.Sync()
+
 	router := mux.NewRouter()
 	router.HandleFunc("/ws", handleConnections)
 	router.HandleFunc("/health", healthCheck)
 	router.HandleFunc("/load-shedding", healthCheckWithLoadShedding)
 
-	log.Printf("Hostname: %s - Server listening on :8080", hostname)
-	log.Fatal(http.ListenAndServe(":8080", router))
+	proxyProtoMode := proxyProtocolMode(os.Getenv("PROXY_PROTOCOL_ACCEPT"))
+
+	listener, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		sugar.Fatalw("listen error", "error", err)
+	}
+	if proxyProtoMode != proxyProtocolNone {
+		listener = &proxyProtocolListener{Listener: listener, mode: proxyProtoMode}
+		sugar.Infow("PROXY protocol accepted", "hostname", hostname, "mode", proxyProtoMode)
+	}
+
+	server := &http.Server{
+		Handler: router,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, clientAddrKey, c.RemoteAddr())
+		},
+	}
+
+	var tlsServer *http.Server
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile != "" && keyFile != "" {
+		reloader, err := newCertReloader(certFile, keyFile)
+		if err != nil {
+			sugar.Fatalw("failed to load TLS certificate", "error", err)
+		}
+		go reloader.watchForReload(sugar)
+
+		tlsListener, err := net.Listen("tcp", ":"+tlsPort)
+		if err != nil {
+			sugar.Fatalw("tls listen error", "error", err)
+		}
+		if proxyProtoMode != proxyProtocolNone {
+			tlsListener = &proxyProtocolListener{Listener: tlsListener, mode: proxyProtoMode}
+		}
+		tlsServer = &http.Server{
+			Handler: router,
+			ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+				return context.WithValue(ctx, clientAddrKey, c.RemoteAddr())
+			},
+			TLSConfig: &tls.Config{GetCertificate: reloader.GetCertificate},
+		}
+		go func() {
+			sugar.Infow("tls server listening", "hostname", hostname, "addr", ":8443")
+			if err := tlsServer.ServeTLS(tlsListener, "", ""); err != nil && err != http.ErrServerClosed {
+				sugar.Errorw("tls serve error", "error", err)
+			}
+		}()
+	}
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		sig := <-sigCh
+
+		grace := shutdownGrace()
+		sugar.Infow("shutting down", "signal", sig.String(), "grace", grace)
+
+		atomic.StoreInt32(&shuttingDown, 1)
+		deregisterInstance()
+		registry.closeAll()
+		waitForDrain(grace)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			sugar.Errorw("server shutdown error", "error", err)
+		}
+		if tlsServer != nil {
+			if err := tlsServer.Shutdown(ctx); err != nil {
+				sugar.Errorw("tls server shutdown error", "error", err)
+			}
+		}
+	}()
+
+	sugar.Infow("server listening", "hostname", hostname, "addr", ":8080")
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		sugar.Fatalw("serve error", "error", err)
+	}
 }
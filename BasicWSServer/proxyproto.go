@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyProtocolHeaderTimeout bounds how long Accept will wait for a PROXY
+// protocol header on a freshly accepted connection. Accept is called
+// serially by http.Server.Serve, so without a deadline a client that
+// connects and never sends a header would block every other connection
+// behind it.
+const proxyProtocolHeaderTimeout = 5 * time.Second
+
+// proxyProtocolMode is read from PROXY_PROTOCOL_ACCEPT. Empty means the
+// listener speaks plain HTTP with no PROXY protocol framing.
+type proxyProtocolMode string
+
+const (
+	proxyProtocolNone proxyProtocolMode = ""
+	proxyProtocolV1   proxyProtocolMode = "v1"
+	proxyProtocolV2   proxyProtocolMode = "v2"
+)
+
+var proxyProtocolV2Signature = []byte{0x0d, 0x0a, 0x0d, 0x0a, 0x00, 0x0d, 0x0a, 0x51, 0x55, 0x49, 0x54, 0x0a}
+
+type clientAddrKeyType struct{}
+
+// clientAddrKey is the context key under which the conn's RemoteAddr is
+// stored: the real client address parsed from a PROXY protocol header when
+// one was accepted, otherwise the TCP peer's own address. It survives into
+// the request context even if something downstream rewrites r.RemoteAddr.
+var clientAddrKey = clientAddrKeyType{}
+
+// clientIPFromContext returns the client IP carried in ctx by ConnContext
+// (the PROXY protocol source address, or the TCP peer when PROXY protocol is
+// disabled or the header carried no usable source address), or "" if no
+// address was recorded in ctx at all.
+func clientIPFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(clientAddrKey).(net.Addr)
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// proxyProtocolListener wraps a net.Listener and, when mode is non-empty,
+// peeks the PROXY protocol header off every accepted connection before the
+// HTTP server ever sees it, substituting the real client address as the
+// conn's RemoteAddr.
+type proxyProtocolListener struct {
+	net.Listener
+	mode proxyProtocolMode
+}
+
+// Accept reads the PROXY protocol header off each accepted connection before
+// handing it to the HTTP server. A connection with a missing or malformed
+// header (an ALB/ECS health check hitting the port directly, a scanner, a
+// stalled client) is logged and dropped rather than returned as an error:
+// net/http.Server.Serve treats any non-Temporary Accept error as fatal and
+// stops serving, so one bad connection must never bubble up past here.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.mode == proxyProtocolNone {
+			return conn, nil
+		}
+
+		conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+		reader := bufio.NewReader(conn)
+		remoteAddr, err := decodeProxyProtocolHeader(reader, l.mode)
+		if err != nil {
+			sugar.Warnw("dropping connection with bad proxy protocol header", "remote_addr", conn.RemoteAddr().String(), "error", err)
+			conn.Close()
+			continue
+		}
+		conn.SetReadDeadline(time.Time{})
+
+		wrapped := &proxyProtocolConn{Conn: conn, reader: reader}
+		if remoteAddr != nil {
+			wrapped.remoteAddr = remoteAddr
+		}
+		return wrapped, nil
+	}
+}
+
+// proxyProtocolConn serves buffered reads (the header may have pulled extra
+// bytes of the actual request into the bufio.Reader) and reports the real
+// client address instead of the load balancer's.
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func decodeProxyProtocolHeader(r *bufio.Reader, mode proxyProtocolMode) (net.Addr, error) {
+	switch mode {
+	case proxyProtocolV1:
+		return decodeProxyProtocolV1(r)
+	case proxyProtocolV2:
+		return decodeProxyProtocolV2(r)
+	default:
+		return nil, nil
+	}
+}
+
+// decodeProxyProtocolV1 parses the ASCII v1 header:
+// "PROXY TCP4 <src> <dst> <sport> <dport>\r\n"
+func decodeProxyProtocolV1(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading v1 header: %v", err)
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, fmt.Errorf("malformed v1 header: %q", line)
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("malformed v1 header: %q", line)
+	}
+
+	srcIP := fields[2]
+	srcPort, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return nil, fmt.Errorf("malformed v1 source port: %v", err)
+	}
+
+	return &net.TCPAddr{IP: net.ParseIP(srcIP), Port: srcPort}, nil
+}
+
+// decodeProxyProtocolV2 parses the binary v2 header: a 12-byte signature,
+// one version/command byte, one address-family/transport byte, a 2-byte
+// big-endian address block length, then the address block itself.
+func decodeProxyProtocolV2(r *bufio.Reader) (net.Addr, error) {
+	sig := make([]byte, len(proxyProtocolV2Signature))
+	if _, err := readFull(r, sig); err != nil {
+		return nil, fmt.Errorf("reading v2 signature: %v", err)
+	}
+	for i, b := range proxyProtocolV2Signature {
+		if sig[i] != b {
+			return nil, fmt.Errorf("bad v2 signature")
+		}
+	}
+
+	verCmd, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("reading v2 version/command: %v", err)
+	}
+	if verCmd>>4 != 0x2 {
+		return nil, fmt.Errorf("unsupported proxy protocol version: %d", verCmd>>4)
+	}
+	command := verCmd & 0x0f
+
+	famProto, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("reading v2 address family: %v", err)
+	}
+	family := famProto >> 4
+
+	lenBuf := make([]byte, 2)
+	if _, err := readFull(r, lenBuf); err != nil {
+		return nil, fmt.Errorf("reading v2 address length: %v", err)
+	}
+	addrLen := binary.BigEndian.Uint16(lenBuf)
+
+	addrBlock := make([]byte, addrLen)
+	if _, err := readFull(r, addrBlock); err != nil {
+		return nil, fmt.Errorf("reading v2 address block: %v", err)
+	}
+
+	// LOCAL connections (health checks from the LB itself) carry no useful
+	// address; command 0x0 means LOCAL, 0x1 means PROXY.
+	if command == 0x0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 0x1: // AF_INET
+		if len(addrBlock) < 12 {
+			return nil, fmt.Errorf("short v2 IPv4 address block")
+		}
+		srcIP := net.IP(addrBlock[0:4])
+		srcPort := binary.BigEndian.Uint16(addrBlock[8:10])
+		return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, nil
+	case 0x2: // AF_INET6
+		if len(addrBlock) < 36 {
+			return nil, fmt.Errorf("short v2 IPv6 address block")
+		}
+		srcIP := net.IP(addrBlock[0:16])
+		srcPort := binary.BigEndian.Uint16(addrBlock[32:34])
+		return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, nil
+	default:
+		// AF_UNSPEC or AF_UNIX: nothing we can turn into a useful net.Addr.
+		return nil, nil
+	}
+}
+
+func readFull(r *bufio.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDecodeProxyProtocolV1(t *testing.T) {
+	cases := []struct {
+		name     string
+		header   string
+		wantIP   string
+		wantPort int
+		wantNil  bool
+		wantErr  bool
+	}{
+		{name: "tcp4", header: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n", wantIP: "192.0.2.1", wantPort: 56324},
+		{name: "tcp6", header: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", wantIP: "2001:db8::1", wantPort: 56324},
+		{name: "unknown", header: "PROXY UNKNOWN\r\n", wantNil: true},
+		{name: "wrong keyword", header: "GET / HTTP/1.1\r\n", wantErr: true},
+		{name: "too few fields", header: "PROXY TCP4 192.0.2.1\r\n", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, err := decodeProxyProtocolV1(bufio.NewReader(strings.NewReader(tc.header)))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got addr %v", addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantNil {
+				if addr != nil {
+					t.Fatalf("addr = %v, want nil", addr)
+				}
+				return
+			}
+			tcpAddr, ok := addr.(*net.TCPAddr)
+			if !ok {
+				t.Fatalf("addr is %T, want *net.TCPAddr", addr)
+			}
+			if tcpAddr.IP.String() != tc.wantIP || tcpAddr.Port != tc.wantPort {
+				t.Fatalf("got %s:%d, want %s:%d", tcpAddr.IP, tcpAddr.Port, tc.wantIP, tc.wantPort)
+			}
+		})
+	}
+}
+
+// buildV2Header reproduces the wire format basic-ws-proxy's
+// encodeProxyProtocolV2 sends, so the decode tests below exercise the same
+// bytes the proxy actually puts on the wire.
+func buildV2Header(command byte, srcIP, dstIP net.IP, srcPort, dstPort uint16) []byte {
+	header := make([]byte, 0, 16+36)
+	header = append(header, proxyProtocolV2Signature...)
+	header = append(header, 0x20|command)
+
+	var addrBlock []byte
+	if ip4 := srcIP.To4(); ip4 != nil {
+		header = append(header, 0x11)
+		addrBlock = make([]byte, 12)
+		copy(addrBlock[0:4], ip4)
+		copy(addrBlock[4:8], dstIP.To4())
+		binary.BigEndian.PutUint16(addrBlock[8:10], srcPort)
+		binary.BigEndian.PutUint16(addrBlock[10:12], dstPort)
+	} else {
+		header = append(header, 0x21)
+		addrBlock = make([]byte, 36)
+		copy(addrBlock[0:16], srcIP.To16())
+		copy(addrBlock[16:32], dstIP.To16())
+		binary.BigEndian.PutUint16(addrBlock[32:34], srcPort)
+		binary.BigEndian.PutUint16(addrBlock[34:36], dstPort)
+	}
+
+	lenBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(lenBuf, uint16(len(addrBlock)))
+	header = append(header, lenBuf...)
+	header = append(header, addrBlock...)
+	return header
+}
+
+func TestDecodeProxyProtocolV2(t *testing.T) {
+	t.Run("ipv4 proxy command", func(t *testing.T) {
+		raw := buildV2Header(0x1, net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2"), 56324, 443)
+		addr, err := decodeProxyProtocolV2(bufio.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tcpAddr, ok := addr.(*net.TCPAddr)
+		if !ok || tcpAddr.IP.String() != "192.0.2.1" || tcpAddr.Port != 56324 {
+			t.Fatalf("got %v, want 192.0.2.1:56324", addr)
+		}
+	})
+
+	t.Run("ipv6 proxy command", func(t *testing.T) {
+		raw := buildV2Header(0x1, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 56324, 443)
+		addr, err := decodeProxyProtocolV2(bufio.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tcpAddr, ok := addr.(*net.TCPAddr)
+		if !ok || !tcpAddr.IP.Equal(net.ParseIP("2001:db8::1")) || tcpAddr.Port != 56324 {
+			t.Fatalf("got %v, want [2001:db8::1]:56324", addr)
+		}
+	})
+
+	t.Run("local command carries no address", func(t *testing.T) {
+		raw := buildV2Header(0x0, net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2"), 0, 0)
+		addr, err := decodeProxyProtocolV2(bufio.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if addr != nil {
+			t.Fatalf("addr = %v, want nil for LOCAL command", addr)
+		}
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		raw := append([]byte("not a proxy protocol hdr"), make([]byte, 20)...)
+		if _, err := decodeProxyProtocolV2(bufio.NewReader(bytes.NewReader(raw))); err == nil {
+			t.Fatal("expected error for bad signature")
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/gorilla/websocket"
+)
+
+// defaultShutdownGrace is how long the server waits for in-flight
+// connections to drain on SIGTERM before forcing the listener closed.
+const defaultShutdownGrace = 30 * time.Second
+
+// shuttingDown flips to 1 as soon as a shutdown signal is received, so
+// /load-shedding starts failing immediately and the proxy stops routing
+// new upgrades here well before the listener actually closes.
+var shuttingDown int32
+
+// connRegistryEntry is a tracked connection: the socket to close, the cancel
+// func that unblocks its read loop, and the write lock that serializes every
+// write to conn. gorilla/websocket allows only one writer at a time, and
+// closeAll's GoingAway frame runs from a different goroutine than the
+// connection's own echo loop, so every writer must take writeMu first.
+type connRegistryEntry struct {
+	conn    *websocket.Conn
+	cancel  context.CancelFunc
+	writeMu *sync.Mutex
+}
+
+// connRegistry tracks every connection handleConnections is currently
+// serving, so a shutdown can close them all instead of waiting out
+// whatever idle timeout each client happens to have.
+type connRegistry struct {
+	mu    sync.RWMutex
+	conns map[string]*connRegistryEntry
+}
+
+var registry = &connRegistry{conns: make(map[string]*connRegistryEntry)}
+
+// register tracks conn under id and returns the write lock the caller must
+// hold around every WriteMessage it issues on conn, so its own writes never
+// race closeAll's.
+func (reg *connRegistry) register(id string, conn *websocket.Conn, cancel context.CancelFunc) *sync.Mutex {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	writeMu := &sync.Mutex{}
+	reg.conns[id] = &connRegistryEntry{conn: conn, cancel: cancel, writeMu: writeMu}
+	return writeMu
+}
+
+func (reg *connRegistry) unregister(id string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.conns, id)
+}
+
+// closeAll sends a GoingAway close frame to every tracked connection and
+// cancels its context so a blocked ReadMessage returns immediately instead
+// of waiting on the client to notice the close frame.
+func (reg *connRegistry) closeAll() {
+	reg.mu.RLock()
+	entries := make([]*connRegistryEntry, 0, len(reg.conns))
+	for _, entry := range reg.conns {
+		entries = append(entries, entry)
+	}
+	reg.mu.RUnlock()
+
+	for _, entry := range entries {
+		entry.writeMu.Lock()
+		entry.conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+		entry.writeMu.Unlock()
+		entry.cancel()
+	}
+}
+
+// waitForDrain blocks until activeConnection reaches zero or grace elapses,
+// whichever comes first, polling rather than requiring every caller thread
+// through a WaitGroup.
+func waitForDrain(grace time.Duration) {
+	deadline := time.After(grace)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if logConnectionCount() == 0 {
+			return
+		}
+		select {
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deregisterInstance removes this task from Cloud Map so the proxy's next
+// cache refresh stops considering it at all, rather than leaving it behind
+// as a stale, unreachable entry.
+func deregisterInstance() {
+	_, err := serviceDiscoveryClient.DeregisterInstance(&servicediscovery.DeregisterInstanceInput{
+		ServiceId:  aws.String(serviceID),
+		InstanceId: aws.String(instanceID),
+	})
+	if err != nil {
+		sugar.Errorw("failed to deregister instance", "error", err)
+	}
+}
+
+func shutdownGrace() time.Duration {
+	if raw := os.Getenv("SHUTDOWN_GRACE"); raw != "" {
+		if v, err := time.ParseDuration(raw); err == nil {
+			return v
+		}
+	}
+	return defaultShutdownGrace
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// instanceView is what GET /instances exposes for each backend;
+// it's the cache entry plus the admin-only drain flag, which Cloud Map
+// never sees.
+type instanceView struct {
+	Instance
+	AdminDrained      bool  `json:"admin_drained"`
+	RoutedConnections int64 `json:"routed_connections_total"`
+}
+
+// connectionView is what GET /connections exposes for a live session.
+type connectionView struct {
+	ID         string `json:"id"`
+	ClientIP   string `json:"client_ip"`
+	InstanceID string `json:"instance_id"`
+	UptimeSecs int64  `json:"uptime_seconds"`
+	BytesIn    int64  `json:"bytes_in"`
+	BytesOut   int64  `json:"bytes_out"`
+}
+
+// requireAdminToken rejects requests that don't carry
+// "Authorization: Bearer <ADMIN_TOKEN>".
+func (p *WSProxy) requireAdminToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authz := r.Header.Get("Authorization")
+		presented := strings.TrimPrefix(authz, "Bearer ")
+		if !strings.HasPrefix(authz, "Bearer ") || subtle.ConstantTimeCompare([]byte(presented), []byte(p.adminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (p *WSProxy) startAdminServer() error {
+	router := mux.NewRouter()
+	router.HandleFunc("/instances", p.requireAdminToken(p.listInstancesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/connections", p.requireAdminToken(p.listConnectionsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/instances/{id}/drain", p.requireAdminToken(p.drainInstanceHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/config", p.requireAdminToken(p.getConfigHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/config", p.requireAdminToken(p.patchConfigHandler)).Methods(http.MethodPatch)
+	router.HandleFunc("/metrics", p.requireAdminToken(p.metricsHandler)).Methods(http.MethodGet)
+
+	p.sugar.Infow("starting admin API", "addr", p.adminAddr)
+	return http.ListenAndServe(p.adminAddr, router)
+}
+
+func (p *WSProxy) listInstancesHandler(w http.ResponseWriter, r *http.Request) {
+	p.cacheMutex.RLock()
+	cache := make([]Instance, len(p.serviceDiscoveryCache))
+	copy(cache, p.serviceDiscoveryCache)
+	p.cacheMutex.RUnlock()
+
+	views := make([]instanceView, 0, len(cache))
+	for _, instance := range cache {
+		views = append(views, instanceView{
+			Instance:          instance,
+			AdminDrained:      p.isDrained(instance.ID),
+			RoutedConnections: atomic.LoadInt64(&p.statsFor(instance.ID).RoutedTotal),
+		})
+	}
+
+	writeJSON(w, views)
+}
+
+func (p *WSProxy) listConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+	p.connMu.RLock()
+	views := make([]connectionView, 0, len(p.connections))
+	for _, rc := range p.connections {
+		views = append(views, connectionView{
+			ID:         rc.ID,
+			ClientIP:   rc.ClientIP,
+			InstanceID: rc.InstanceID,
+			UptimeSecs: int64(time.Since(rc.StartedAt).Seconds()),
+			BytesIn:    atomic.LoadInt64(&rc.BytesIn),
+			BytesOut:   atomic.LoadInt64(&rc.BytesOut),
+		})
+	}
+	p.connMu.RUnlock()
+
+	writeJSON(w, views)
+}
+
+// drainInstanceHandler takes id out of rotation for new connections; its
+// existing connections are left to finish normally, and the instance is
+// automatically returned to rotation once they all have (see
+// WSProxy.undrainIfEmpty), so there is no separate un-drain endpoint.
+func (p *WSProxy) drainInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	p.drainInstance(id)
+	p.sugar.Infow("instance drained via admin API", "instance_id", id)
+	// An instance with no connections left is already empty; no connection
+	// will finish to trigger the clear, so check once here.
+	p.undrainIfEmpty(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *WSProxy) getConfigHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, p.getConfig())
+}
+
+func (p *WSProxy) patchConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var patch struct {
+		Algorithm            *string `json:"algorithm"`
+		CacheRefreshInterval *string `json:"cache_refresh_interval"`
+		RetryBehavior        *string `json:"retry_behavior"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	p.configMu.Lock()
+	if patch.Algorithm != nil {
+		p.config.Algorithm = *patch.Algorithm
+	}
+	if patch.CacheRefreshInterval != nil {
+		d, err := time.ParseDuration(*patch.CacheRefreshInterval)
+		if err != nil {
+			p.configMu.Unlock()
+			http.Error(w, fmt.Sprintf("invalid cache_refresh_interval: %v", err), http.StatusBadRequest)
+			return
+		}
+		p.config.CacheRefreshInterval = d
+	}
+	if patch.RetryBehavior != nil {
+		p.config.RetryBehavior = *patch.RetryBehavior
+	}
+	updated := p.config
+	p.configMu.Unlock()
+
+	p.sugar.Infow("config updated via admin API", "algorithm", updated.Algorithm, "cache_refresh_interval", updated.CacheRefreshInterval, "retry_behavior", updated.RetryBehavior)
+	writeJSON(w, updated)
+}
+
+func (p *WSProxy) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	p.cacheMutex.RLock()
+	cache := make([]Instance, len(p.serviceDiscoveryCache))
+	copy(cache, p.serviceDiscoveryCache)
+	refreshLatency := p.lastRefreshLatency
+	p.cacheMutex.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintf(w, "# HELP wsproxy_upstreams_total Number of upstream instances currently known.\n")
+	fmt.Fprintf(w, "# TYPE wsproxy_upstreams_total gauge\n")
+	fmt.Fprintf(w, "wsproxy_upstreams_total %d\n", len(cache))
+
+	fmt.Fprintf(w, "# HELP wsproxy_routed_connections Connections routed to a backend, by instance.\n")
+	fmt.Fprintf(w, "# TYPE wsproxy_routed_connections gauge\n")
+	for _, instance := range cache {
+		stats := p.statsFor(instance.ID)
+		fmt.Fprintf(w, "wsproxy_routed_connections{instance_id=%q} %d\n", instance.ID, atomic.LoadInt64(&stats.ActiveConnections))
+	}
+
+	fmt.Fprintf(w, "# HELP wsproxy_dropped_requests_total Requests dropped because no instance was available.\n")
+	fmt.Fprintf(w, "# TYPE wsproxy_dropped_requests_total counter\n")
+	fmt.Fprintf(w, "wsproxy_dropped_requests_total %d\n", atomic.LoadInt64(&p.droppedRequests))
+
+	fmt.Fprintf(w, "# HELP wsproxy_service_discovery_refresh_latency_seconds Latency of the last Cloud Map ListInstances call.\n")
+	fmt.Fprintf(w, "# TYPE wsproxy_service_discovery_refresh_latency_seconds gauge\n")
+	fmt.Fprintf(w, "wsproxy_service_discovery_refresh_latency_seconds %f\n", refreshLatency.Seconds())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
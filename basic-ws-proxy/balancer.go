@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancer picks the backend instance a new upgrade should be routed to.
+// WSProxy holds one of each supported strategy and dispatches to whichever
+// the live config names, so the algorithm can change without a restart.
+type Balancer interface {
+	Pick(ctx context.Context, r *http.Request) (*Instance, error)
+}
+
+// leastConnBalancer routes to whichever healthy, non-draining instance
+// currently reports the fewest active connections. This is the original
+// strategy the proxy shipped with.
+type leastConnBalancer struct {
+	p *WSProxy
+}
+
+func (b *leastConnBalancer) Pick(ctx context.Context, r *http.Request) (*Instance, error) {
+	return b.p.getLeastLoadedInstance()
+}
+
+// roundRobinBalancer cycles through the eligible instances in cache order,
+// giving each an even share of new upgrades regardless of its current load.
+type roundRobinBalancer struct {
+	p *WSProxy
+	n uint64
+}
+
+func (b *roundRobinBalancer) Pick(ctx context.Context, r *http.Request) (*Instance, error) {
+	eligible := b.p.eligibleInstances()
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("no instances available")
+	}
+
+	n := atomic.AddUint64(&b.n, 1) - 1
+	instance := eligible[n%uint64(len(eligible))]
+	return &instance, nil
+}
+
+// ringPoint is one vnode on the consistent-hash ring.
+type ringPoint struct {
+	hash       uint64
+	instanceID string
+}
+
+// consistentHashBalancer implements Ketama-style consistent hashing so a
+// client presenting the same key (cookie, header, or query param, per
+// WSProxy.stickyKey) keeps landing on the same backend across reconnects,
+// and only a fraction of keys move when the instance set changes.
+type consistentHashBalancer struct {
+	p      *WSProxy
+	vnodes int
+
+	mu   sync.RWMutex
+	ring []ringPoint
+	ids  []string // sorted instance IDs the ring was last built from
+}
+
+func newConsistentHashBalancer(p *WSProxy, vnodes int) *consistentHashBalancer {
+	return &consistentHashBalancer{p: p, vnodes: vnodes}
+}
+
+func (b *consistentHashBalancer) Pick(ctx context.Context, r *http.Request) (*Instance, error) {
+	key := b.p.stickyKey(r)
+	if key == "" {
+		// No hash key on the request: fall back to least-connections rather
+		// than sending every unkeyed client to the same ring point.
+		return b.p.getLeastLoadedInstance()
+	}
+
+	if instance, ok := b.lookup(key); ok {
+		return instance, nil
+	}
+	return b.p.getLeastLoadedInstance()
+}
+
+func (b *consistentHashBalancer) lookup(key string) (*Instance, bool) {
+	b.mu.RLock()
+	ring := b.ring
+	b.mu.RUnlock()
+
+	if len(ring) == 0 {
+		return nil, false
+	}
+
+	h := hashRingKey(key)
+	idx := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	if idx == len(ring) {
+		idx = 0
+	}
+
+	return b.p.instanceByID(ring[idx].instanceID)
+}
+
+// rebuild regenerates the ring from the current instance set, skipping the
+// O(n*vnodes) rebuild entirely when the set of instance IDs hasn't changed
+// since the last refresh.
+func (b *consistentHashBalancer) rebuild(instances []Instance) {
+	ids := make([]string, len(instances))
+	for i, instance := range instances {
+		ids[i] = instance.ID
+	}
+	sort.Strings(ids)
+
+	b.mu.RLock()
+	unchanged := stringSlicesEqual(ids, b.ids)
+	b.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	ring := make([]ringPoint, 0, len(instances)*b.vnodes)
+	for _, instance := range instances {
+		for i := 0; i < b.vnodes; i++ {
+			h := hashRingKey(fmt.Sprintf("%s#%d", instance.ID, i))
+			ring = append(ring, ringPoint{hash: h, instanceID: instance.ID})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	b.mu.Lock()
+	b.ring = ring
+	b.ids = ids
+	b.mu.Unlock()
+}
+
+func hashRingKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
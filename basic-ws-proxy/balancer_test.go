@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConsistentHashBalancerRebuildAndLookup(t *testing.T) {
+	p := &WSProxy{}
+	p.serviceDiscoveryCache = []Instance{
+		{ID: "i-1", Healthy: true},
+		{ID: "i-2", Healthy: true},
+		{ID: "i-3", Healthy: true},
+	}
+
+	b := newConsistentHashBalancer(p, 4)
+	b.rebuild(p.eligibleInstances())
+
+	if want := len(p.serviceDiscoveryCache) * 4; len(b.ring) != want {
+		t.Fatalf("ring size = %d, want %d", len(b.ring), want)
+	}
+	for i := 1; i < len(b.ring); i++ {
+		if b.ring[i-1].hash > b.ring[i].hash {
+			t.Fatalf("ring not sorted at index %d", i)
+		}
+	}
+
+	// A key whose hash lands beyond the last ring point must wrap around to
+	// the first entry (sort.Search returns len(ring) in that case) instead
+	// of failing the lookup.
+	maxHash := b.ring[len(b.ring)-1].hash
+	var wrapKey string
+	for i := 0; ; i++ {
+		k := fmt.Sprintf("wrap-%d", i)
+		if hashRingKey(k) > maxHash {
+			wrapKey = k
+			break
+		}
+		if i > 1_000_000 {
+			t.Fatal("could not find a key hashing beyond the ring")
+		}
+	}
+	instance, ok := b.lookup(wrapKey)
+	if !ok || instance.ID != b.ring[0].instanceID {
+		t.Fatalf("lookup(%q) = %v, %v, want ring[0] instance %q", wrapKey, instance, ok, b.ring[0].instanceID)
+	}
+
+	// The same key must always resolve to the same instance.
+	first, ok := b.lookup("sticky-key")
+	if !ok {
+		t.Fatal("lookup(\"sticky-key\") = false, want true")
+	}
+	second, _ := b.lookup("sticky-key")
+	if first.ID != second.ID {
+		t.Fatalf("lookup not stable: %q vs %q", first.ID, second.ID)
+	}
+}
+
+func TestConsistentHashBalancerRebuildSkipsUnchangedSet(t *testing.T) {
+	p := &WSProxy{}
+	p.serviceDiscoveryCache = []Instance{
+		{ID: "i-1", Healthy: true},
+		{ID: "i-2", Healthy: true},
+	}
+
+	b := newConsistentHashBalancer(p, 4)
+	b.rebuild(p.eligibleInstances())
+	ringBefore := b.ring
+
+	b.rebuild(p.eligibleInstances())
+	if &b.ring[0] != &ringBefore[0] {
+		t.Fatal("rebuild regenerated the ring even though the instance set was unchanged")
+	}
+}
+
+func TestConsistentHashBalancerPickFallsBackWithoutStickyKey(t *testing.T) {
+	p := &WSProxy{}
+	p.serviceDiscoveryCache = []Instance{
+		{ID: "i-1", Healthy: true, ActiveConnections: 5},
+		{ID: "i-2", Healthy: true, ActiveConnections: 1},
+	}
+
+	b := newConsistentHashBalancer(p, 4)
+	b.rebuild(p.eligibleInstances())
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	instance, err := b.Pick(req.Context(), req)
+	if err != nil {
+		t.Fatalf("Pick returned error: %v", err)
+	}
+	if instance.ID != "i-2" {
+		t.Fatalf("Pick() = %q, want least-loaded instance %q", instance.ID, "i-2")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newLogger builds the zap logger used by the whole process. LOG_LEVEL
+// (debug|info|warn|error, default info) and LOG_FORMAT (json|console,
+// default console) are honored so routing and load-shedding events can be
+// shipped straight into CloudWatch Logs Insights without regex parsing.
+func newLogger() (*zap.Logger, error) {
+	level := zapcore.InfoLevel
+	if lvl := os.Getenv("LOG_LEVEL"); lvl != "" {
+		if err := level.Set(lvl); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL %q: %v", lvl, err)
+		}
+	}
+
+	var cfg zap.Config
+	if os.Getenv("LOG_FORMAT") == "json" {
+		cfg = zap.NewProductionConfig()
+	} else {
+		cfg = zap.NewDevelopmentConfig()
+	}
+	cfg.Level = zap.NewAtomicLevelAt(level)
+
+	return cfg.Build()
+}
@@ -1,48 +1,210 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
 )
 
+// connIDHeader carries the per-connection correlation ID from the proxy to
+// the backend so log lines on both sides of the hop can be joined.
+const connIDHeader = "X-Conn-ID"
+
 type Instance struct {
 	ID                string
 	Host              string
+	Scheme            string // "ws" or "wss", from the SCHEME Cloud Map attribute
 	ActiveConnections int
+	Healthy           bool
+	Draining          bool
 }
 
 type WSProxy struct {
 	serverAddr            string
+	adminAddr             string
+	adminToken            string
 	serviceDiscoveryID    string
 	serviceDiscoveryNS    string
 	serviceDiscoveryCache []Instance
 	cacheMutex            sync.RWMutex
+	lastRefreshLatency    time.Duration
 	sdClient              *servicediscovery.ServiceDiscovery
+	upgrader              websocket.Upgrader
+	dialer                websocket.Dialer
+	stickyHeader          string
+	stickyCookie          string
+	stickyQueryParam      string
+	proxyProtocolEmit     proxyProtocolMode
+	upstreamScheme        string
+	logger                *zap.Logger
+	sugar                 *zap.SugaredLogger
+
+	config   ProxyConfig
+	configMu sync.RWMutex
+
+	leastConnBalancer      *leastConnBalancer
+	roundRobinBalancer     *roundRobinBalancer
+	consistentHashBalancer *consistentHashBalancer
+
+	drainMu sync.Mutex
+	drained map[string]bool
+
+	connMu      sync.RWMutex
+	connections map[string]*routedConnection
+
+	instanceStatsMu sync.Mutex
+	instanceStats   map[string]*instanceStats
+
+	droppedRequests int64
+
+	// shuttingDown flips to 1 on SIGTERM so proxyHandler stops accepting new
+	// upgrades while the connections already pumped are left to finish.
+	shuttingDown int32
+}
+
+// ProxyConfig is the live-tunable behavior exposed through the admin API's
+// GET/PATCH /config, so the LB algorithm and cache refresh interval can
+// change without a restart. RetryBehavior is stored and reported through
+// /config only: proxyHandler does not read it, and a failed backend dial is
+// not retried.
+type ProxyConfig struct {
+	Algorithm            string        `json:"algorithm"`
+	CacheRefreshInterval time.Duration `json:"cache_refresh_interval"`
+	RetryBehavior        string        `json:"retry_behavior"`
+}
+
+// instanceStats accumulates per-backend connection counters used by the
+// admin API and /metrics. Fields are updated with atomic ops rather than a
+// mutex; per-frame byte counts live on routedConnection, also atomic.
+type instanceStats struct {
+	ActiveConnections int64
+	RoutedTotal       int64
+}
+
+// routedConnection is a live session tracked for GET /connections: one per
+// client currently pumped through to a backend.
+type routedConnection struct {
+	ID         string
+	ClientIP   string
+	InstanceID string
+	StartedAt  time.Time
+	BytesIn    int64
+	BytesOut   int64
 }
 
 func NewWSProxy(serverAddr string) *WSProxy {
 	sess := session.Must(session.NewSession())
 	sdClient := servicediscovery.New(sess)
 
-	return &WSProxy{
+	logger, err := newLogger()
+	if err != nil {
+		// Logging can't be set up yet, so this is the one place we panic
+		// instead of logging.
+		panic(fmt.Sprintf("failed to build logger: %v", err))
+	}
+
+	p := &WSProxy{
 		serverAddr:         serverAddr,
+		adminAddr:          os.Getenv("ADMIN_ADDR"),
+		adminToken:         os.Getenv("ADMIN_TOKEN"),
 		serviceDiscoveryID: os.Getenv("CLOUD_MAP_SERVICE_ID"),
 		serviceDiscoveryNS: os.Getenv("CLOUD_MAP_NAMESPACE_ID"),
 		sdClient:           sdClient,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				return true // Allow all origins (for testing; restrict in production)
+			},
+		},
+		stickyHeader:      os.Getenv("STICKY_SESSION_HEADER"),
+		stickyCookie:      os.Getenv("STICKY_SESSION_COOKIE"),
+		stickyQueryParam:  os.Getenv("STICKY_SESSION_QUERY_PARAM"),
+		proxyProtocolEmit: proxyProtocolMode(os.Getenv("PROXY_PROTOCOL_EMIT")),
+		upstreamScheme:    "ws",
+		logger:            logger,
+		sugar:             logger.Sugar(),
+		config: ProxyConfig{
+			Algorithm:            "least_conn",
+			CacheRefreshInterval: 10 * time.Second,
+			RetryBehavior:        "retry_next_instance",
+		},
+		drained:       make(map[string]bool),
+		connections:   make(map[string]*routedConnection),
+		instanceStats: make(map[string]*instanceStats),
 	}
+
+	if algo := os.Getenv("LB_ALGORITHM"); algo != "" {
+		p.config.Algorithm = algo
+	}
+
+	if scheme := os.Getenv("UPSTREAM_SCHEME"); scheme != "" {
+		p.upstreamScheme = scheme
+	}
+
+	if p.adminAddr == "" {
+		p.adminAddr = ":9090"
+	}
+
+	p.leastConnBalancer = &leastConnBalancer{p: p}
+	p.roundRobinBalancer = &roundRobinBalancer{p: p}
+	p.consistentHashBalancer = newConsistentHashBalancer(p, 160)
+
+	p.dialer = *websocket.DefaultDialer
+	p.dialer.NetDialContext = p.dialUpstream
+	p.dialer.TLSClientConfig = upstreamTLSConfig(p.sugar)
+	return p
+}
+
+// dialUpstream opens the raw TCP connection to a backend and, when
+// PROXY_PROTOCOL_EMIT is set, writes the PROXY protocol preamble carrying
+// the original client's address before handing the conn back to the
+// websocket dialer to perform the HTTP upgrade over it.
+func (p *WSProxy) dialUpstream(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.proxyProtocolEmit == proxyProtocolNone {
+		return conn, nil
+	}
+
+	clientAddr, _ := ctx.Value(clientAddrKey).(net.Addr)
+	if clientAddr == nil {
+		return conn, nil
+	}
+
+	header, err := encodeProxyProtocolHeader(p.proxyProtocolEmit, clientAddr, conn.RemoteAddr())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.Write(header); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("writing proxy protocol header: %v", err)
+	}
+	return conn, nil
 }
 
 func (p *WSProxy) updateServiceDiscoveryCache() error {
+	start := time.Now()
 	input := &servicediscovery.ListInstancesInput{
 		ServiceId: aws.String(p.serviceDiscoveryID),
 	}
@@ -59,30 +221,145 @@ func (p *WSProxy) updateServiceDiscoveryCache() error {
 			connections, _ = strconv.Atoi(*val)
 		}
 
+		draining := false
+		if val, ok := inst.Attributes["DRAINING"]; ok {
+			draining, _ = strconv.ParseBool(*val)
+		}
+
+		healthy := true
+		if val, ok := inst.Attributes["HEALTHY"]; ok {
+			healthy, _ = strconv.ParseBool(*val)
+		}
+
+		scheme := p.upstreamScheme
+		if val, ok := inst.Attributes["SCHEME"]; ok && *val != "" {
+			scheme = *val
+		}
+
+		// AWS_INSTANCE_IPV4 carries no port, so a wss instance needs its
+		// TLS_PORT attribute appended or a dial would fall through to the
+		// scheme's default port (443), where nothing listens.
+		host := *inst.Attributes["AWS_INSTANCE_IPV4"]
+		if scheme == "wss" {
+			if port, ok := inst.Attributes["TLS_PORT"]; ok && *port != "" {
+				host = net.JoinHostPort(host, *port)
+			}
+		}
+
 		instances = append(instances, Instance{
 			ID:                *inst.Id,
-			Host:              *inst.Attributes["AWS_INSTANCE_IPV4"],
+			Host:              host,
+			Scheme:            scheme,
 			ActiveConnections: connections,
+			Healthy:           healthy,
+			Draining:          draining,
 		})
 	}
 
 	p.cacheMutex.Lock()
 	p.serviceDiscoveryCache = instances
+	p.lastRefreshLatency = time.Since(start)
 	p.cacheMutex.Unlock()
 
+	p.consistentHashBalancer.rebuild(p.eligibleInstances())
+
 	return nil
 }
 
-func (p *WSProxy) getLeastLoadedInstance() (*Instance, error) {
+// eligibleInstances returns the instances that may receive new upgrades,
+// i.e. everything that is healthy and not draining. Unhealthy or draining
+// nodes stay in the cache (and in Cloud Map) so existing connections and
+// health-check tooling keep seeing them, they just stop being routed to.
+// An instance drained through the admin API (isDrained) is excluded the
+// same way even though Cloud Map still reports it healthy.
+func (p *WSProxy) eligibleInstances() []Instance {
 	p.cacheMutex.RLock()
-	defer p.cacheMutex.RUnlock()
+	cache := p.serviceDiscoveryCache
+	p.cacheMutex.RUnlock()
+
+	eligible := make([]Instance, 0, len(cache))
+	for _, instance := range cache {
+		if instance.Healthy && !instance.Draining && !p.isDrained(instance.ID) {
+			eligible = append(eligible, instance)
+		}
+	}
+	return eligible
+}
+
+// instanceByID returns the cached eligible instance with the given ID, used
+// by the consistent-hash balancer to resolve a ring point back to an
+// Instance.
+func (p *WSProxy) instanceByID(id string) (*Instance, bool) {
+	for _, instance := range p.eligibleInstances() {
+		if instance.ID == id {
+			return &instance, true
+		}
+	}
+	return nil, false
+}
+
+// isDrained reports whether id was marked ineligible for new routing via
+// POST /instances/{id}/drain.
+func (p *WSProxy) isDrained(id string) bool {
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+	return p.drained[id]
+}
 
-	if len(p.serviceDiscoveryCache) == 0 {
+// drainInstance marks id ineligible for new routing. Existing connections
+// already pumped to it are left alone to finish on their own; once its
+// active-connection count reaches zero, undrainIfEmpty automatically clears
+// the flag, so "drain until it empties" doesn't strand a backend out of
+// rotation forever once it's actually empty.
+func (p *WSProxy) drainInstance(id string) {
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+	p.drained[id] = true
+}
+
+// undrainIfEmpty clears id's drain flag once it has no tracked active
+// connections left, called after every connection to id finishes.
+func (p *WSProxy) undrainIfEmpty(id string) {
+	if atomic.LoadInt64(&p.statsFor(id).ActiveConnections) > 0 {
+		return
+	}
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+	if p.drained[id] {
+		delete(p.drained, id)
+		p.sugar.Infow("instance auto-undrained after draining empty", "instance_id", id)
+	}
+}
+
+// statsFor returns the shared counters for a backend, creating them on
+// first use so a newly discovered instance starts at zero rather than
+// panicking on a missing map entry.
+func (p *WSProxy) statsFor(id string) *instanceStats {
+	p.instanceStatsMu.Lock()
+	defer p.instanceStatsMu.Unlock()
+	s, ok := p.instanceStats[id]
+	if !ok {
+		s = &instanceStats{}
+		p.instanceStats[id] = s
+	}
+	return s
+}
+
+// getConfig returns a copy of the live, admin-tunable configuration.
+func (p *WSProxy) getConfig() ProxyConfig {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+	return p.config
+}
+
+func (p *WSProxy) getLeastLoadedInstance() (*Instance, error) {
+	eligible := p.eligibleInstances()
+	if len(eligible) == 0 {
 		return nil, fmt.Errorf("no instances available")
 	}
 
-	leastLoaded := p.serviceDiscoveryCache[0]
-	for _, instance := range p.serviceDiscoveryCache {
+	leastLoaded := eligible[0]
+	for _, instance := range eligible {
 		if instance.ActiveConnections < leastLoaded.ActiveConnections {
 			leastLoaded = instance
 		}
@@ -91,38 +368,195 @@ func (p *WSProxy) getLeastLoadedInstance() (*Instance, error) {
 	return &leastLoaded, nil
 }
 
+// stickyKey extracts the configurable header, cookie, or query param value
+// used as the consistent-hash ring key, so a reconnecting client keeps
+// landing on the same backend. It returns "" when no source is configured
+// or present on the request.
+func (p *WSProxy) stickyKey(r *http.Request) string {
+	if p.stickyHeader != "" {
+		if v := r.Header.Get(p.stickyHeader); v != "" {
+			return v
+		}
+	}
+	if p.stickyCookie != "" {
+		if c, err := r.Cookie(p.stickyCookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+	if p.stickyQueryParam != "" {
+		if v := r.URL.Query().Get(p.stickyQueryParam); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// selectInstance picks the backend for a new upgrade by delegating to
+// whichever Balancer the live config currently names.
+func (p *WSProxy) selectInstance(r *http.Request) (*Instance, error) {
+	return p.balancerFor(p.getConfig().Algorithm).Pick(r.Context(), r)
+}
+
+// balancerFor resolves an algorithm name to its Balancer, defaulting to
+// least-connections for an unrecognized or unset value.
+func (p *WSProxy) balancerFor(algorithm string) Balancer {
+	switch algorithm {
+	case "round_robin":
+		return p.roundRobinBalancer
+	case "consistent_hash":
+		return p.consistentHashBalancer
+	default:
+		return p.leastConnBalancer
+	}
+}
+
+// hopHeaders are stripped before forwarding the client's handshake headers
+// upstream; they are either regenerated by the dialer or only meaningful to
+// the proxy's own listener.
+var hopHeaders = []string{
+	"Connection",
+	"Upgrade",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+}
+
+// forwardHeaders builds the header set sent to the backend on dial,
+// carrying over cookies, auth, subprotocols, and origin so the backend sees
+// the same handshake the client sent the proxy.
+func forwardHeaders(r *http.Request) http.Header {
+	headers := r.Header.Clone()
+	for _, h := range hopHeaders {
+		headers.Del(h)
+	}
+	return headers
+}
+
 func (p *WSProxy) proxyHandler(w http.ResponseWriter, r *http.Request) {
-	instance, err := p.getLeastLoadedInstance()
+	if atomic.LoadInt32(&p.shuttingDown) == 1 {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	connID := uuid.NewString()
+	log := p.sugar.With("conn_id", connID)
+
+	instance, err := p.selectInstance(r)
 	if err != nil {
-		log.Printf("Failed to get instance: %v", err)
+		atomic.AddInt64(&p.droppedRequests, 1)
+		log.Errorw("failed to get instance", "error", err)
 		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 		return
 	}
+	log.Infow("routing decision", "instance_id", instance.ID, "instance_active_connections", instance.ActiveConnections)
+
+	scheme := instance.Scheme
+	if scheme == "" {
+		scheme = p.upstreamScheme
+	}
+	targetURL := fmt.Sprintf("%s://%s/ws", scheme, instance.Host)
+
+	ctx := r.Context()
+	if tcpAddr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = context.WithValue(ctx, clientAddrKey, tcpAddr)
+	}
+
+	forwarded := forwardHeaders(r)
+	forwarded.Set(connIDHeader, connID)
+
+	backendConn, resp, err := p.dialer.DialContext(ctx, targetURL, forwarded)
+	if err != nil {
+		status := http.StatusBadGateway
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		log.Errorw("failed to dial backend", "instance_id", instance.ID, "host", instance.Host, "error", err)
+		http.Error(w, "Bad Gateway", status)
+		return
+	}
+	defer backendConn.Close()
 
-	targetURL := fmt.Sprintf("ws://%s/ws", instance.Host)
-	target, err := url.Parse(targetURL)
+	clientConn, err := p.upgrader.Upgrade(w, r, http.Header{connIDHeader: {connID}})
 	if err != nil {
-		log.Printf("Failed to parse target URL: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorw("failed to upgrade client connection", "error", err)
 		return
 	}
+	defer clientConn.Close()
+
+	clientIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		clientIP = host
+	}
+	rc := &routedConnection{
+		ID:         connID,
+		ClientIP:   clientIP,
+		InstanceID: instance.ID,
+		StartedAt:  time.Now(),
+	}
+	stats := p.statsFor(instance.ID)
+	atomic.AddInt64(&stats.ActiveConnections, 1)
+	atomic.AddInt64(&stats.RoutedTotal, 1)
+	p.registerConnection(rc)
+	defer func() {
+		atomic.AddInt64(&stats.ActiveConnections, -1)
+		p.unregisterConnection(rc.ID)
+		p.undrainIfEmpty(instance.ID)
+	}()
+
+	pumpConns(p.logger, rc, clientConn, backendConn)
+}
+
+// registerConnection tracks rc so it shows up in GET /connections.
+func (p *WSProxy) registerConnection(rc *routedConnection) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.connections[rc.ID] = rc
+}
+
+// connectionCount reports how many connections are still being pumped, so a
+// shutdown can wait for pumpConns to drain them instead of cutting them off.
+func (p *WSProxy) connectionCount() int {
+	p.connMu.RLock()
+	defer p.connMu.RUnlock()
+	return len(p.connections)
+}
+
+func (p *WSProxy) unregisterConnection(id string) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	delete(p.connections, id)
+}
 
-	// Update the request URL
-	r.URL.Host = target.Host
-	r.URL.Scheme = target.Scheme
-	r.URL.Path = target.Path
+// pumpConns copies frames in both directions between the client and the
+// chosen backend until either side closes, then tears down the other side.
+func pumpConns(logger *zap.Logger, rc *routedConnection, clientConn, backendConn *websocket.Conn) {
+	errc := make(chan error, 2)
 
-	// Update headers
-	r.Header.Set("Host", target.Host)
-	r.Host = target.Host
+	go func() {
+		errc <- copyFrames(backendConn, clientConn, &rc.BytesIn)
+	}()
+	go func() {
+		errc <- copyFrames(clientConn, backendConn, &rc.BytesOut)
+	}()
 
-	redirectUrl := url.URL{
-		Scheme: r.URL.Scheme,
-		Host:   r.URL.Host,
-		Path:   r.URL.Path,
+	if err := <-errc; err != nil {
+		logger.Debug("connection pump ended", zap.String("conn_id", rc.ID), zap.Error(err))
 	}
+}
 
-	http.Redirect(w, r, redirectUrl.String(), http.StatusTemporaryRedirect)
+// copyFrames relays frames from src to dst, tallying their size in
+// *counted with an atomic add so per-frame accounting never takes a lock.
+func copyFrames(dst, src *websocket.Conn, counted *int64) error {
+	for {
+		messageType, message, err := src.ReadMessage()
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(counted, int64(len(message)))
+		if err := dst.WriteMessage(messageType, message); err != nil {
+			return err
+		}
+	}
 }
 
 func (p *WSProxy) healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -133,24 +567,103 @@ func (p *WSProxy) healthHandler(w http.ResponseWriter, r *http.Request) {
 func (p *WSProxy) Start() error {
 	// Start service discovery cache update routine
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		for range ticker.C {
+		for {
 			if err := p.updateServiceDiscoveryCache(); err != nil {
-				log.Printf("Failed to update service discovery cache: %v", err)
+				p.sugar.Errorw("failed to update service discovery cache", "error", err)
 			}
+			time.Sleep(p.getConfig().CacheRefreshInterval)
+		}
+	}()
+
+	if p.adminToken != "" {
+		go func() {
+			if err := p.startAdminServer(); err != nil {
+				p.sugar.Errorw("admin server stopped", "error", err)
+			}
+		}()
+	} else {
+		p.sugar.Warnw("ADMIN_TOKEN not set, admin API disabled")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", p.proxyHandler)
+	mux.HandleFunc("/health", p.healthHandler)
+
+	server := &http.Server{Addr: p.serverAddr, Handler: mux}
+
+	tlsConfig, _, err := listenerTLSConfig(p.sugar)
+	if err != nil {
+		return fmt.Errorf("configuring TLS listener: %v", err)
+	}
+	if tlsConfig != nil {
+		server.TLSConfig = tlsConfig
+	}
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		sig := <-sigCh
+
+		grace := shutdownGrace()
+		p.sugar.Infow("shutting down", "signal", sig.String(), "grace", grace)
+
+		atomic.StoreInt32(&p.shuttingDown, 1)
+		p.waitForDrain(grace)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			p.sugar.Errorw("server shutdown error", "error", err)
 		}
 	}()
 
-	http.HandleFunc("/ws", p.proxyHandler)
-	http.HandleFunc("/health", p.healthHandler)
+	p.sugar.Infow("starting websocket proxy server", "addr", p.serverAddr, "tls", tlsConfig != nil)
+	var serveErr error
+	if tlsConfig != nil {
+		serveErr = server.ListenAndServeTLS("", "")
+	} else {
+		serveErr = server.ListenAndServe()
+	}
+	if serveErr != nil && serveErr != http.ErrServerClosed {
+		return serveErr
+	}
+	return nil
+}
 
-	log.Printf("Starting WebSocket proxy server on %s", p.serverAddr)
-	return http.ListenAndServe(p.serverAddr, nil)
+// waitForDrain blocks until no connections are being pumped or grace
+// elapses, whichever comes first.
+func (p *WSProxy) waitForDrain(grace time.Duration) {
+	deadline := time.After(grace)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if p.connectionCount() == 0 {
+			return
+		}
+		select {
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+const defaultShutdownGrace = 30 * time.Second
+
+func shutdownGrace() time.Duration {
+	if raw := os.Getenv("SHUTDOWN_GRACE"); raw != "" {
+		if v, err := time.ParseDuration(raw); err == nil {
+			return v
+		}
+	}
+	return defaultShutdownGrace
 }
 
 func main() {
 	proxy := NewWSProxy(":8080")
+	defer proxy.logger.Sync()
 	if err := proxy.Start(); err != nil {
-		log.Fatal("ListenAndServe error:", err)
+		proxy.sugar.Fatalw("listen and serve error", "error", err)
 	}
 }
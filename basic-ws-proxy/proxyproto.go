@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// proxyProtocolMode is read from PROXY_PROTOCOL_EMIT. Empty means dial
+// upstream with a plain TCP connection, no PROXY protocol preamble.
+type proxyProtocolMode string
+
+const (
+	proxyProtocolNone proxyProtocolMode = ""
+	proxyProtocolV1   proxyProtocolMode = "v1"
+	proxyProtocolV2   proxyProtocolMode = "v2"
+)
+
+var proxyProtocolV2Signature = []byte{0x0d, 0x0a, 0x0d, 0x0a, 0x00, 0x0d, 0x0a, 0x51, 0x55, 0x49, 0x54, 0x0a}
+
+type clientAddrKeyType struct{}
+
+// clientAddrKey carries the inbound client's address through to the dial
+// that opens the upstream connection, so the PROXY protocol emitter knows
+// whose address to put in the header.
+var clientAddrKey = clientAddrKeyType{}
+
+// encodeProxyProtocolHeader builds the PROXY protocol preamble to send to
+// the backend ahead of the WebSocket upgrade request, so the backend's own
+// PROXY_PROTOCOL_ACCEPT listener learns the real client address instead of
+// this proxy's.
+func encodeProxyProtocolHeader(mode proxyProtocolMode, src, dst net.Addr) ([]byte, error) {
+	srcTCP, ok := src.(*net.TCPAddr)
+	if !ok {
+		return nil, fmt.Errorf("proxy protocol: source address %v is not TCP", src)
+	}
+	dstTCP, ok := dst.(*net.TCPAddr)
+	if !ok {
+		return nil, fmt.Errorf("proxy protocol: destination address %v is not TCP", dst)
+	}
+
+	switch mode {
+	case proxyProtocolV1:
+		return encodeProxyProtocolV1(srcTCP, dstTCP), nil
+	case proxyProtocolV2:
+		return encodeProxyProtocolV2(srcTCP, dstTCP), nil
+	default:
+		return nil, nil
+	}
+}
+
+func encodeProxyProtocolV1(src, dst *net.TCPAddr) []byte {
+	family := "TCP4"
+	if src.IP.To4() == nil {
+		family = "TCP6"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, src.IP.String(), dst.IP.String(), src.Port, dst.Port))
+}
+
+func encodeProxyProtocolV2(src, dst *net.TCPAddr) []byte {
+	header := make([]byte, 0, 16+36)
+	header = append(header, proxyProtocolV2Signature...)
+	header = append(header, 0x21) // version 2, command PROXY
+
+	var addrBlock []byte
+	if src.IP.To4() != nil {
+		header = append(header, 0x11) // AF_INET, STREAM
+		addrBlock = make([]byte, 12)
+		copy(addrBlock[0:4], src.IP.To4())
+		copy(addrBlock[4:8], dst.IP.To4())
+		binary.BigEndian.PutUint16(addrBlock[8:10], uint16(src.Port))
+		binary.BigEndian.PutUint16(addrBlock[10:12], uint16(dst.Port))
+	} else {
+		header = append(header, 0x21) // AF_INET6, STREAM
+		addrBlock = make([]byte, 36)
+		copy(addrBlock[0:16], src.IP.To16())
+		copy(addrBlock[16:32], dst.IP.To16())
+		binary.BigEndian.PutUint16(addrBlock[32:34], uint16(src.Port))
+		binary.BigEndian.PutUint16(addrBlock[34:36], uint16(dst.Port))
+	}
+
+	lenBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(lenBuf, uint16(len(addrBlock)))
+	header = append(header, lenBuf...)
+	header = append(header, addrBlock...)
+	return header
+}
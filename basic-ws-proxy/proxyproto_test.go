@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestEncodeProxyProtocolV1(t *testing.T) {
+	cases := []struct {
+		name       string
+		src, dst   *net.TCPAddr
+		wantPrefix string
+	}{
+		{
+			name:       "ipv4",
+			src:        &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324},
+			dst:        &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443},
+			wantPrefix: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443",
+		},
+		{
+			name:       "ipv6",
+			src:        &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324},
+			dst:        &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443},
+			wantPrefix: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := string(encodeProxyProtocolV1(tc.src, tc.dst))
+			if !strings.HasPrefix(header, tc.wantPrefix) {
+				t.Fatalf("header = %q, want prefix %q", header, tc.wantPrefix)
+			}
+			if !strings.HasSuffix(header, "\r\n") {
+				t.Fatalf("header = %q, missing CRLF terminator", header)
+			}
+		})
+	}
+}
+
+func TestEncodeProxyProtocolV2(t *testing.T) {
+	t.Run("ipv4", func(t *testing.T) {
+		src := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}
+		dst := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443}
+		header := encodeProxyProtocolV2(src, dst)
+
+		if !bytes.HasPrefix(header, proxyProtocolV2Signature) {
+			t.Fatalf("header missing v2 signature: %x", header)
+		}
+		addrLen := binary.BigEndian.Uint16(header[14:16])
+		if addrLen != 12 {
+			t.Fatalf("ipv4 address block length = %d, want 12", addrLen)
+		}
+		gotIP := net.IP(header[16:20])
+		gotPort := binary.BigEndian.Uint16(header[24:26])
+		if !gotIP.Equal(src.IP) || int(gotPort) != src.Port {
+			t.Fatalf("got %s:%d, want %s:%d", gotIP, gotPort, src.IP, src.Port)
+		}
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		src := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}
+		dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+		header := encodeProxyProtocolV2(src, dst)
+
+		addrLen := binary.BigEndian.Uint16(header[14:16])
+		if addrLen != 36 {
+			t.Fatalf("ipv6 address block length = %d, want 36", addrLen)
+		}
+		gotIP := net.IP(header[16:32])
+		gotPort := binary.BigEndian.Uint16(header[48:50])
+		if !gotIP.Equal(src.IP) || int(gotPort) != src.Port {
+			t.Fatalf("got %s:%d, want %s:%d", gotIP, gotPort, src.IP, src.Port)
+		}
+	})
+}
+
+func TestEncodeProxyProtocolHeaderModeNone(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443}
+
+	header, err := encodeProxyProtocolHeader(proxyProtocolNone, src, dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if header != nil {
+		t.Fatalf("header = %x, want nil for proxyProtocolNone", header)
+	}
+}
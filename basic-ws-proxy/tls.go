@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// certReloader holds the proxy's listener certificate and re-reads it from
+// disk on demand, so a cert rotation doesn't require dropping every pumped
+// connection for a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS key pair: %v", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// watchForReload re-reads the certificate/key pair on every SIGHUP, which
+// lets operators rotate certs without restarting the process.
+func (r *certReloader) watchForReload(sugar *zap.SugaredLogger) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	for range sigCh {
+		if err := r.reload(); err != nil {
+			sugar.Errorw("failed to reload TLS certificate", "cert_file", r.certFile, "error", err)
+			continue
+		}
+		sugar.Infow("reloaded TLS certificate", "cert_file", r.certFile)
+	}
+}
+
+// listenerTLSConfig builds the proxy's own listener TLS config from
+// TLS_CERT_FILE/TLS_KEY_FILE, optionally requiring and verifying client
+// certificates when TLS_CLIENT_CA is set. Returns nil, nil, nil if TLS
+// isn't configured, so callers can fall back to a plain listener.
+func listenerTLSConfig(sugar *zap.SugaredLogger) (*tls.Config, *certReloader, error) {
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile == "" || keyFile == "" {
+		return nil, nil, nil
+	}
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	go reloader.watchForReload(sugar)
+
+	cfg := &tls.Config{GetCertificate: reloader.GetCertificate}
+
+	if caFile := os.Getenv("TLS_CLIENT_CA"); caFile != "" {
+		caCert, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading TLS_CLIENT_CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, nil, fmt.Errorf("no certificates found in TLS_CLIENT_CA %s", caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, reloader, nil
+}
+
+// upstreamTLSConfig builds the TLS config used when dialing a backend over
+// wss, honoring UPSTREAM_TLS_SKIP_VERIFY and a custom UPSTREAM_TLS_CA bundle
+// for backends presenting certs a public root store wouldn't recognize.
+func upstreamTLSConfig(sugar *zap.SugaredLogger) *tls.Config {
+	cfg := &tls.Config{}
+
+	if skip, _ := os.LookupEnv("UPSTREAM_TLS_SKIP_VERIFY"); skip == "true" {
+		cfg.InsecureSkipVerify = true
+	}
+
+	if caFile := os.Getenv("UPSTREAM_TLS_CA"); caFile != "" {
+		caCert, err := os.ReadFile(caFile)
+		if err != nil {
+			sugar.Errorw("failed to read UPSTREAM_TLS_CA", "ca_file", caFile, "error", err)
+			return cfg
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			sugar.Errorw("no certificates found in UPSTREAM_TLS_CA", "ca_file", caFile)
+			return cfg
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg
+}